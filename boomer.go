@@ -2,21 +2,45 @@
 package boomer
 
 import (
+	"context"
 	"crypto/tls"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/time/rate"
+)
+
+// Supported values for Boomer.Engine.
+const (
+	EngineNetHTTP  = "net/http"
+	EngineFastHTTP = "fasthttp"
 )
 
-type result struct {
-	err           error
-	statusCode    int
-	duration      time.Duration
-	contentLength int64
+// Result is the outcome of a single request, delivered to Reporters and
+// to the channel returned by Boomer.Results as the run progresses.
+type Result struct {
+	Err        error
+	StatusCode int
+
+	// Outcome is the protocol-agnostic counterpart to StatusCode: HTTP
+	// requests (via the net/http and fasthttp engines) leave it empty,
+	// since StatusCode already says everything; a Protocol that isn't
+	// HTTP (GRPCProtocol's status codes, WebSocketProtocol's close
+	// codes) fills it in instead, so ReportResult.OutcomeDist has a
+	// single field to tally across every protocol.
+	Outcome string
+
+	Duration      time.Duration
+	ContentLength int64
+	ReadBytes     int64
+	WriteBytes    int64
 }
 
 type Boomer struct {
@@ -25,16 +49,29 @@ type Boomer struct {
 
 	RequestBody string
 
-	// N is the total number of requests to make.
+	// Engine selects the HTTP client implementation used to fire
+	// requests: EngineNetHTTP (the default) or EngineFastHTTP. The
+	// fasthttp engine trades net/http's compatibility for a
+	// substantially higher single-box RPS ceiling.
+	Engine string
+
+	// N is the total number of requests to make. Zero means unbounded:
+	// keep going until Duration elapses or ctx is cancelled.
 	N int
 
 	// C is the concurrency level, the number of concurrent workers to run.
 	C int
 
+	// Duration bounds how long Run keeps issuing requests. Zero means
+	// no time bound; N (or ctx cancellation) decides when to stop.
+	// Setting both N and Duration stops at whichever comes first.
+	Duration time.Duration
+
 	// Timeout in seconds.
 	Timeout int
 
-	// Qps is the rate limit.
+	// Qps is the rate limit, enforced with a token-bucket limiter so
+	// bursts and sub-microsecond intervals (Qps > 1e6) behave correctly.
 	Qps int
 
 	// AllowInsecure is an option to allow insecure TLS/SSL certificates.
@@ -54,24 +91,127 @@ type Boomer struct {
 	// to be fully consumed.
 	ReadAll bool
 
-	results chan *result
+	// RecordAllLatencies keeps the exact latency of every request in
+	// memory in addition to the streaming histogram, surfaced as
+	// ReportResult.ExactLatencies. It's an escape hatch for small runs
+	// that want exact percentiles rather than the histogram's
+	// log-linear approximation; leave it false for large runs to avoid
+	// the slice's O(N) memory cost.
+	RecordAllLatencies bool
+
+	// Reporters receive every Result as it happens, plus periodic
+	// Snapshots and a final ReportResult, so callers can plug in a live
+	// terminal UI, a CSV/JSONL writer, or a metrics exporter instead of
+	// waiting for Run to return. The built-in histogram-based summary
+	// that Run returns is itself just another Reporter under the hood.
+	Reporters []Reporter
+
+	// Provider, if set, builds the request for every iteration in place
+	// of the fixed Request/RequestBody pair, letting each worker (and
+	// each virtual-user iteration within it) hit a different URL,
+	// header set, or body. See provider.go for the concrete providers
+	// (TemplateProvider, CSVProvider, JSONLProvider, ChainProvider).
+	Provider RequestProvider
+
+	// Protocol, if set, takes over firing every request from the
+	// built-in net/http and fasthttp engines (Engine is ignored), for
+	// transports HTTP can't represent. See protocol.go for the concrete
+	// protocols (GRPCProtocol, WebSocketProtocol).
+	Protocol Protocol
+
+	results    chan Result
+	resultsOut chan Result
 }
 
+// Results returns a channel that receives a Result for every request as
+// it completes. It must be called before Run, and the caller must keep
+// draining it for the duration of the run — like the Reporters, it's
+// fed from the same single dispatcher goroutine that drives the final
+// report, so a stalled consumer stalls the whole run.
+func (b *Boomer) Results() <-chan Result {
+	if b.resultsOut == nil {
+		b.resultsOut = make(chan Result, b.C)
+	}
+	return b.resultsOut
+}
+
+// defaultTickInterval is how often Run recomputes a Snapshot and hands
+// it to every Reporter's OnTick. Reporters that only care about a
+// coarser cadence (e.g. TickReporter) self-throttle from there.
+const defaultTickInterval = 200 * time.Millisecond
+
 // Run makes all the requests, prints the summary. It blocks until
-// all work is done.
-func (b *Boomer) Run() *ReportResult {
-	b.results = make(chan *result, b.N)
+// all work is done, Duration elapses, or ctx is cancelled, whichever
+// happens first; in-flight client.Do calls are cancelled cleanly
+// through ctx. With EngineFastHTTP, ctx is only checked before each
+// call, so a call already in flight runs to completion.
+func (b *Boomer) Run(ctx context.Context) *ReportResult {
+	b.results = make(chan Result, b.C)
 
 	start := time.Now()
 
-	b.runWorkers()
-	result := newReport(b.N, b.results, time.Now().Sub(start)).finalize()
+	rpt := newReport(b.N, 0, b.RecordAllLatencies)
+	reporters := append([]Reporter{rpt}, b.Reporters...)
+
+	done := make(chan *ReportResult, 1)
+	go func() {
+		ticker := time.NewTicker(defaultTickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case res, ok := <-b.results:
+				if !ok {
+					final := rpt.Report()
+					for _, rp := range reporters {
+						rp.OnFinish(final)
+					}
+					if b.resultsOut != nil {
+						close(b.resultsOut)
+					}
+					done <- final
+					return
+				}
+				for _, rp := range reporters {
+					rp.OnResult(res)
+				}
+				if b.resultsOut != nil {
+					b.resultsOut <- res
+				}
+			case <-ticker.C:
+				snap := rpt.Snapshot(time.Now().Sub(start))
+				for _, rp := range reporters {
+					rp.OnTick(snap)
+				}
+			}
+		}
+	}()
+
+	b.runWorkers(ctx)
+	rpt.total = time.Now().Sub(start)
 	close(b.results)
 
-	return result
+	return <-done
 }
 
-func (b *Boomer) runWorker(wg *sync.WaitGroup, ch chan *http.Request) {
+// nextRequest builds the request for one iteration of workerID's loop.
+// With no Provider set, it's the same cloned static Request every time;
+// with a Provider, workerID and seq (the worker's own 1-based iteration
+// count) are handed to it so providers like ChainProvider can keep
+// per-virtual-user state across iterations.
+func (b *Boomer) nextRequest(ctx context.Context, workerID, seq int) (*http.Request, error) {
+	if b.Provider != nil {
+		return b.Provider.Next(ctx, workerID, seq)
+	}
+	return cloneRequest(b.Request, b.RequestBody).WithContext(ctx), nil
+}
+
+func (b *Boomer) runWorker(ctx context.Context, workerID int, wg *sync.WaitGroup, permits chan struct{}) {
+	// mc tracks the connection currently in use by this worker. Workers
+	// only ever have one request in flight at a time, so it's safe to
+	// snapshot mc's counters before and after client.Do to attribute
+	// wire bytes to the request that produced them.
+	var mc *MyConn
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: b.AllowInsecure,
@@ -81,13 +221,39 @@ func (b *Boomer) runWorker(wg *sync.WaitGroup, ch chan *http.Request) {
 		// TODO(jbd): Add dial timeout.
 		TLSHandshakeTimeout: time.Duration(b.Timeout) * time.Millisecond,
 		Proxy:               http.ProxyURL(b.ProxyAddr),
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			mc = newMyConn(conn)
+			return mc, nil
+		},
 	}
 	client := &http.Client{Transport: tr}
-	for req := range ch {
+	var seq int
+	for range permits {
+		seq++
+
+		req, err := b.nextRequest(ctx, workerID, seq)
+		if err != nil {
+			b.results <- Result{Err: err}
+			wg.Done()
+			continue
+		}
+
+		// Started only once nextRequest has returned, so a
+		// ChainProvider's unmeasured setup steps (e.g. a login call)
+		// never leak into Result.Duration.
 		s := time.Now()
 
 		var code int
 		var size int64
+		var beforeRead, beforeWrite int64
+		prev := mc
+		if mc != nil {
+			beforeRead, beforeWrite = mc.ReadBytes(), mc.WriteBytes()
+		}
 
 		resp, err := client.Do(req)
 		if err == nil {
@@ -99,38 +265,188 @@ func (b *Boomer) runWorker(wg *sync.WaitGroup, ch chan *http.Request) {
 			resp.Body.Close()
 		}
 
+		var readBytes, writeBytes int64
+		if mc != nil {
+			if mc != prev {
+				// A fresh connection was dialed during Do; all of its
+				// traffic belongs to this request.
+				beforeRead, beforeWrite = 0, 0
+			}
+			readBytes, writeBytes = mc.ReadBytes()-beforeRead, mc.WriteBytes()-beforeWrite
+		}
+
+		// Send before Done: runWorkers' wg.Wait only unblocks once every
+		// result has actually reached the channel, so it's safe for Run
+		// to close(b.results) right after Wait returns.
+		b.results <- Result{
+			StatusCode:    code,
+			Duration:      time.Now().Sub(s),
+			Err:           err,
+			ContentLength: size,
+			ReadBytes:     readBytes,
+			WriteBytes:    writeBytes,
+		}
 		wg.Done()
-		// b.incProgress()
-		b.results <- &result{
-			statusCode:    code,
-			duration:      time.Now().Sub(s),
-			err:           err,
-			contentLength: size,
+	}
+}
+
+// runFastWorker is the fasthttp-backed sibling of runWorker, used when
+// Boomer.Engine is EngineFastHTTP.
+func (b *Boomer) runFastWorker(ctx context.Context, workerID int, wg *sync.WaitGroup, permits chan struct{}) {
+	var mc *MyConn
+	client := &fasthttp.Client{
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: b.AllowInsecure,
+		},
+		DisableHeaderNamesNormalizing: false,
+		Dial: func(addr string) (net.Conn, error) {
+			// Timeout is in milliseconds and, like net.Dialer's own
+			// Timeout field, zero means no timeout; fasthttp's
+			// DialTimeout instead treats a zero duration as "already
+			// expired" and fails every dial immediately, so it's only
+			// used once Timeout is actually set.
+			var conn net.Conn
+			var err error
+			if b.Timeout > 0 {
+				conn, err = fasthttp.DialTimeout(addr, time.Duration(b.Timeout)*time.Millisecond)
+			} else {
+				conn, err = fasthttp.Dial(addr)
+			}
+			if err != nil {
+				return nil, err
+			}
+			mc = newMyConn(conn)
+			return mc, nil
+		},
+	}
+	var seq int
+	for range permits {
+		seq++
+
+		req, err := b.nextRequest(ctx, workerID, seq)
+		if err != nil {
+			b.results <- Result{Err: err}
+			wg.Done()
+			continue
+		}
+
+		// Started only once nextRequest has returned, so a
+		// ChainProvider's unmeasured setup steps (e.g. a login call)
+		// never leak into Result.Duration.
+		s := time.Now()
+
+		freq := fasthttp.AcquireRequest()
+		fresp := fasthttp.AcquireResponse()
+		if err := cloneFastRequest(freq, req); err != nil {
+			fasthttp.ReleaseRequest(freq)
+			fasthttp.ReleaseResponse(fresp)
+			b.results <- Result{Err: err, Duration: time.Now().Sub(s)}
+			wg.Done()
+			continue
+		}
+
+		var beforeRead, beforeWrite int64
+		prev := mc
+		if mc != nil {
+			beforeRead, beforeWrite = mc.ReadBytes(), mc.WriteBytes()
 		}
+
+		// fasthttp's Client.Do has no context parameter, so cancellation
+		// is checked before issuing the call rather than interrupting a
+		// call already in flight.
+		var code int
+		var size int64
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			err = client.Do(freq, fresp)
+			if err == nil {
+				code = fresp.StatusCode()
+				size = int64(len(fresp.Body()))
+			}
+		}
+
+		var readBytes, writeBytes int64
+		if mc != nil {
+			if mc != prev {
+				beforeRead, beforeWrite = 0, 0
+			}
+			readBytes, writeBytes = mc.ReadBytes()-beforeRead, mc.WriteBytes()-beforeWrite
+		}
+
+		fasthttp.ReleaseRequest(freq)
+		fasthttp.ReleaseResponse(fresp)
+
+		b.results <- Result{
+			StatusCode:    code,
+			Duration:      time.Now().Sub(s),
+			Err:           err,
+			ContentLength: size,
+			ReadBytes:     readBytes,
+			WriteBytes:    writeBytes,
+		}
+		wg.Done()
 	}
 }
 
-func (b *Boomer) runWorkers() {
+// runWorkers drives an open-model workload: a rate.Limiter paces job
+// production so bursts and QPS above 1e6 behave correctly (time.Tick's
+// 1e6/Qps µs interval silently truncates to 0 there), and the job
+// channel is unbuffered so a slow, low-Qps producer never blocks on a
+// pre-allocated N-sized buffer. Production stops when N requests have
+// been dispatched, Duration elapses, or ctx is cancelled.
+func (b *Boomer) runWorkers(ctx context.Context) {
 	var wg sync.WaitGroup
-	wg.Add(b.N)
 
-	var throttle <-chan time.Time
-	if b.Qps > 0 {
-		throttle = time.Tick(time.Duration(1e6/(b.Qps)) * time.Microsecond)
+	worker := b.runWorker
+	switch {
+	case b.Protocol != nil:
+		worker = b.runProtocolWorker
+	case b.Engine == EngineFastHTTP:
+		worker = b.runFastWorker
 	}
 
-	jobsch := make(chan *http.Request, b.N)
+	permits := make(chan struct{})
 	for i := 0; i < b.C; i++ {
-		go b.runWorker(&wg, jobsch)
+		go worker(ctx, i, &wg, permits)
 	}
 
-	for i := 0; i < b.N; i++ {
-		if b.Qps > 0 {
-			<-throttle
+	var limiter *rate.Limiter
+	if b.Qps > 0 {
+		burst := b.Qps
+		if burst < 1 {
+			burst = 1
 		}
-		jobsch <- cloneRequest(b.Request, b.RequestBody)
+		limiter = rate.NewLimiter(rate.Limit(b.Qps), burst)
+	}
+
+	var deadline <-chan time.Time
+	if b.Duration > 0 {
+		timer := time.NewTimer(b.Duration)
+		defer timer.Stop()
+		deadline = timer.C
 	}
-	close(jobsch)
+
+dispatch:
+	for i := 0; b.N <= 0 || i < b.N; i++ {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				break dispatch
+			}
+		}
+
+		wg.Add(1)
+		select {
+		case permits <- struct{}{}:
+		case <-ctx.Done():
+			wg.Done()
+			break dispatch
+		case <-deadline:
+			wg.Done()
+			break dispatch
+		}
+	}
+	close(permits)
 	wg.Wait()
 }
 
@@ -148,3 +464,28 @@ func cloneRequest(r *http.Request, body string) *http.Request {
 	r2.Body = ioutil.NopCloser(strings.NewReader(body))
 	return r2
 }
+
+// cloneFastRequest populates freq from r, the fasthttp equivalent of
+// cloneRequest. r.Body is read and closed, since it's already the
+// per-iteration body (static or Provider-built) rather than something
+// callers still need afterwards.
+func cloneFastRequest(freq *fasthttp.Request, r *http.Request) error {
+	freq.SetRequestURI(r.URL.String())
+	freq.Header.SetMethod(r.Method)
+	for k, vs := range r.Header {
+		for _, v := range vs {
+			freq.Header.Add(k, v)
+		}
+	}
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(body) > 0 {
+			freq.SetBody(body)
+		}
+	}
+	return nil
+}
@@ -2,13 +2,20 @@ package boomer
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestN(t *testing.T) {
@@ -25,7 +32,7 @@ func TestN(t *testing.T) {
 		N:       20,
 		C:       2,
 	}
-	boomer.Run()
+	boomer.Run(context.Background())
 	if count != 20 {
 		t.Errorf("Expected to boom 20 times, found %v", count)
 	}
@@ -54,10 +61,170 @@ func TestQps(t *testing.T) {
 		}
 		wg.Done()
 	})
-	go boomer.Run()
+	go boomer.Run(context.Background())
 	wg.Wait()
 }
 
+func TestDuration(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, int64(1))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:  req,
+		C:        2,
+		Duration: 100 * time.Millisecond,
+	}
+	boomer.Run(context.Background())
+	if count == 0 {
+		t.Errorf("Expected at least one request before Duration elapsed, found %v", count)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, int64(1))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       1000000,
+		C:       2,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	boomer.Run(ctx)
+	if atomic.LoadInt64(&count) >= 1000000 {
+		t.Errorf("Expected ctx cancellation to stop the run early, found %v", count)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:            req,
+		N:                  20,
+		C:                  2,
+		RecordAllLatencies: true,
+	}
+	result := boomer.Run(context.Background())
+	if _, ok := result.LatencyDist["50%"]; !ok {
+		t.Errorf("Expected a p50 latency in LatencyDist, found %v", result.LatencyDist)
+	}
+	if len(result.Histogram) == 0 {
+		t.Errorf("Expected a non-empty Histogram")
+	}
+	var total int64
+	for _, bucket := range result.Histogram {
+		total += bucket.Count
+	}
+	if total != 20 {
+		t.Errorf("Expected histogram buckets to account for 20 requests, found %v", total)
+	}
+	if len(result.ExactLatencies) != 20 {
+		t.Fatalf("Expected 20 entries in ExactLatencies, found %v", len(result.ExactLatencies))
+	}
+	for i := 1; i < len(result.ExactLatencies); i++ {
+		if result.ExactLatencies[i] < result.ExactLatencies[i-1] {
+			t.Errorf("Expected ExactLatencies to be sorted ascending, found %v", result.ExactLatencies)
+			break
+		}
+	}
+}
+
+func TestHistogramWithoutRecordAllLatencies(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       20,
+		C:       2,
+	}
+	result := boomer.Run(context.Background())
+	if result.ExactLatencies != nil {
+		t.Errorf("Expected nil ExactLatencies when RecordAllLatencies is unset, found %v", result.ExactLatencies)
+	}
+}
+
+func TestResultsChannel(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       10,
+		C:       2,
+	}
+
+	results := boomer.Results()
+	var seen int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range results {
+			atomic.AddInt64(&seen, 1)
+		}
+	}()
+
+	boomer.Run(context.Background())
+	wg.Wait()
+
+	if seen != 10 {
+		t.Errorf("Expected 10 results on the Results channel, found %v", seen)
+	}
+}
+
+type countingReporter struct {
+	results  int64
+	ticks    int64
+	finished int64
+}
+
+func (c *countingReporter) OnResult(Result)        { atomic.AddInt64(&c.results, 1) }
+func (c *countingReporter) OnTick(Snapshot)        { atomic.AddInt64(&c.ticks, 1) }
+func (c *countingReporter) OnFinish(*ReportResult) { atomic.AddInt64(&c.finished, 1) }
+
+func TestReporters(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	reporter := &countingReporter{}
+	boomer := &Boomer{
+		Request:   req,
+		N:         10,
+		C:         2,
+		Reporters: []Reporter{reporter},
+	}
+	boomer.Run(context.Background())
+
+	if reporter.results != 10 {
+		t.Errorf("Expected 10 OnResult calls, found %v", reporter.results)
+	}
+	if reporter.finished != 1 {
+		t.Errorf("Expected exactly 1 OnFinish call, found %v", reporter.finished)
+	}
+}
+
 func TestRequest(t *testing.T) {
 	var uri, contentType, some, method, auth string
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -81,13 +248,16 @@ func TestRequest(t *testing.T) {
 		N:       1,
 		C:       1,
 	}
-	boomer.Run()
+	boomer.Run(context.Background())
 	if uri != "/" {
 		t.Errorf("Uri is expected to be /, %v is found", uri)
 	}
 	if contentType != "text/html" {
 		t.Errorf("Content type is expected to be text/html, %v is found", contentType)
 	}
+	if method != "GET" {
+		t.Errorf("Method is expected to be GET, %v is found", method)
+	}
 	if some != "value" {
 		t.Errorf("X-some header is expected to be value, %v is found", some)
 	}
@@ -96,6 +266,305 @@ func TestRequest(t *testing.T) {
 	}
 }
 
+func TestFastHTTPEngine(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.Write([]byte("pong"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		Engine:  EngineFastHTTP,
+		N:       10,
+		C:       2,
+	}
+	result := boomer.Run(context.Background())
+	if count != 10 {
+		t.Errorf("Expected to boom 10 times, found %v", count)
+	}
+	if result.ErrorDist["dialing to the given TCP address timed out"] != 0 {
+		t.Errorf("Expected dials to succeed with Timeout unset, found %v", result.ErrorDist)
+	}
+	if result.Summary.BytesReadPerSec == 0 {
+		t.Errorf("Expected a non-zero BytesReadPerSec, found %v", result.Summary.BytesReadPerSec)
+	}
+	if result.Summary.BytesWrittenPerSec == 0 {
+		t.Errorf("Expected a non-zero BytesWrittenPerSec, found %v", result.Summary.BytesWrittenPerSec)
+	}
+}
+
+func TestTemplateProvider(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	provider := &TemplateProvider{
+		URLTemplate:  server.URL + "/users/{{.Seq}}",
+		BodyTemplate: "{{uuid}}",
+	}
+	boomer := &Boomer{
+		Provider: provider,
+		N:        5,
+		C:        1,
+	}
+	boomer.Run(context.Background())
+
+	if len(paths) != 5 {
+		t.Fatalf("Expected 5 requests, found %v", len(paths))
+	}
+	for i, p := range paths {
+		want := "/users/" + string(rune('1'+i))
+		if p != want {
+			t.Errorf("Request %d: expected path %v, found %v", i, want, p)
+		}
+	}
+}
+
+func TestCSVProvider(t *testing.T) {
+	f, err := ioutil.TempFile("", "boomer-users-*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("email\na@example.com\nb@example.com\n")
+	f.Close()
+
+	var emails []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		emails = append(emails, r.Header.Get("X-email"))
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	provider := &CSVProvider{
+		Path: f.Name(),
+		Build: func(row map[string]string, workerID, seq int) (*http.Request, error) {
+			req, err := http.NewRequest("GET", server.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("X-email", row["email"])
+			return req, nil
+		},
+	}
+	boomer := &Boomer{
+		Provider: provider,
+		N:        4,
+		C:        1,
+	}
+	boomer.Run(context.Background())
+
+	if len(emails) != 4 {
+		t.Fatalf("Expected 4 requests, found %v", len(emails))
+	}
+	want := []string{"a@example.com", "b@example.com", "a@example.com", "b@example.com"}
+	for i, e := range emails {
+		if e != want[i] {
+			t.Errorf("Request %d: expected email %v, found %v", i, want[i], e)
+		}
+	}
+}
+
+func TestCSVProviderRoundRobinAcrossWorkers(t *testing.T) {
+	f, err := ioutil.TempFile("", "boomer-rows-*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("id\nr0\nr1\nr2\nr3\nr4\n")
+	f.Close()
+
+	var ids []string
+	provider := &CSVProvider{
+		Path: f.Name(),
+		Build: func(row map[string]string, workerID, seq int) (*http.Request, error) {
+			ids = append(ids, row["id"])
+			return http.NewRequest("GET", "http://example.com", nil)
+		},
+	}
+
+	// Four workers all calling Next for their own first iteration
+	// (workerID varies, seq is every worker's own 1) must land on four
+	// different rows, not the same one four times: row selection has
+	// to be driven by a counter shared across workers, not by the
+	// per-worker seq.
+	for workerID := 0; workerID < 4; workerID++ {
+		if _, err := provider.Next(context.Background(), workerID, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("Expected 4 distinct rows across 4 workers' first iteration, found duplicate %v in %v", id, ids)
+		}
+		seen[id] = true
+	}
+}
+
+func TestChainProvider(t *testing.T) {
+	const loginDelay = 50 * time.Millisecond
+
+	var authed []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			time.Sleep(loginDelay)
+			json.NewEncoder(w).Encode(map[string]string{"token": "secret-token"})
+		case "/me":
+			mu.Lock()
+			authed = append(authed, r.Header.Get("Authorization"))
+			mu.Unlock()
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	provider := &ChainProvider{
+		Steps: []ChainStep{
+			{
+				Request: func(vars map[string]string) (*http.Request, error) {
+					return http.NewRequest("POST", server.URL+"/login", nil)
+				},
+				ExtractJSON: map[string]string{"token": "token"},
+			},
+			{
+				Request: func(vars map[string]string) (*http.Request, error) {
+					req, err := http.NewRequest("GET", server.URL+"/me", nil)
+					if err != nil {
+						return nil, err
+					}
+					req.Header.Set("Authorization", "Bearer "+vars["token"])
+					return req, nil
+				},
+			},
+		},
+	}
+	boomer := &Boomer{
+		Provider: provider,
+		N:        3,
+		C:        1,
+	}
+
+	results := boomer.Results()
+	var durations []time.Duration
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for res := range results {
+			durations = append(durations, res.Duration)
+		}
+	}()
+
+	boomer.Run(context.Background())
+	<-done
+
+	for _, d := range durations {
+		if d >= loginDelay {
+			t.Errorf("Expected Duration to exclude the unmeasured login step's %v delay, found %v", loginDelay, d)
+		}
+	}
+
+	if len(authed) != 3 {
+		t.Fatalf("Expected 3 authenticated requests, found %v", len(authed))
+	}
+	for i, a := range authed {
+		if a != "Bearer secret-token" {
+			t.Errorf("Request %d: expected bearer token, found %v", i, a)
+		}
+	}
+}
+
+type fakeProtocol struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeProtocol) Do(ctx context.Context, req *http.Request) (Result, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if req.Header.Get(WorkerIDHeader) == "" {
+		return Result{}, fmt.Errorf("expected %s to be set", WorkerIDHeader)
+	}
+	return Result{Outcome: "ok"}, nil
+}
+
+func TestProtocol(t *testing.T) {
+	req, _ := http.NewRequest("GET", "unused://", nil)
+	protocol := &fakeProtocol{}
+	boomer := &Boomer{
+		Request:  req,
+		Protocol: protocol,
+		N:        6,
+		C:        2,
+	}
+	result := boomer.Run(context.Background())
+
+	if protocol.calls != 6 {
+		t.Fatalf("Expected 6 calls to Protocol.Do, found %v", protocol.calls)
+	}
+	if result.OutcomeDist["ok"] != 6 {
+		t.Errorf("Expected OutcomeDist[\"ok\"] == 6, found %v", result.OutcomeDist)
+	}
+}
+
+func TestWebSocketProtocolContextCancel(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Read the request message, then never reply, simulating a
+		// peer that stops responding mid-exchange.
+		conn.ReadMessage()
+		select {}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	req, _ := http.NewRequest("GET", "unused://", nil)
+	boomer := &Boomer{
+		Request:  req,
+		Protocol: &WebSocketProtocol{URL: wsURL},
+		N:        1,
+		C:        1,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		boomer.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected Run to return once ctx was cancelled, but it hung")
+	}
+}
+
 func TestBody(t *testing.T) {
 	var count int64
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -114,7 +583,7 @@ func TestBody(t *testing.T) {
 		N:           10,
 		C:           1,
 	}
-	boomer.Run()
+	boomer.Run(context.Background())
 	if count != 10 {
 		t.Errorf("Expected to boom 10 times, found %v", count)
 	}
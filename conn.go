@@ -0,0 +1,44 @@
+package boomer
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// MyConn wraps a net.Conn and keeps atomic counters of the bytes that
+// have actually moved across the wire, so callers can measure true
+// throughput even when the response is chunked/streamed and
+// resp.ContentLength is -1.
+type MyConn struct {
+	net.Conn
+
+	read  int64
+	write int64
+}
+
+// newMyConn wraps conn for byte accounting.
+func newMyConn(conn net.Conn) *MyConn {
+	return &MyConn{Conn: conn}
+}
+
+func (c *MyConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *MyConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.write, int64(n))
+	return n, err
+}
+
+// ReadBytes returns the number of bytes read off the wire so far.
+func (c *MyConn) ReadBytes() int64 {
+	return atomic.LoadInt64(&c.read)
+}
+
+// WriteBytes returns the number of bytes written to the wire so far.
+func (c *MyConn) WriteBytes() int64 {
+	return atomic.LoadInt64(&c.write)
+}
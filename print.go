@@ -2,8 +2,23 @@ package boomer
 
 import (
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
 	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// histogramLowestMicros, histogramHighestMicros and
+// histogramSignificantFigures configure the log-linear latency
+// histogram: 1µs..60s tracked to 3 significant digits, which covers the
+// realistic range of HTTP latencies in 17,408 int64 counters
+// regardless of how many requests are made.
+const (
+	histogramLowestMicros       = 1
+	histogramHighestMicros      = 60 * 1e6
+	histogramSignificantFigures = 3
 )
 
 type ResponseTime struct {
@@ -12,6 +27,15 @@ type ResponseTime struct {
 	BarLen int
 }
 
+// HistogramBucket is one bucket of the raw latency histogram, exposed
+// so callers can re-derive arbitrary percentiles or merge histograms
+// across processes without access to the internal hdrhistogram state.
+type HistogramBucket struct {
+	FromSecond float64
+	ToSecond   float64
+	Count      int64
+}
+
 type ReportResult struct {
 	Summary struct {
 		TotalSecond    float64
@@ -21,86 +45,181 @@ type ReportResult struct {
 		RequestsPerSec float64
 		TotalSize      int64
 		SizePerRequest int64
+
+		// BytesReadPerSec and BytesWrittenPerSec are wire-level
+		// throughput, measured on the underlying connection rather
+		// than resp.ContentLength, so they stay accurate for
+		// chunked/streamed responses where ContentLength is -1.
+		BytesReadPerSec    float64
+		BytesWrittenPerSec float64
 	}
 
 	StatusCodeDist map[int]int
-	ResponseTimes  []ResponseTime
-	LatencyDist    map[string]float64
-	ErrorDist      map[string]int
+
+	// OutcomeDist is StatusCodeDist generalized across protocols: HTTP
+	// results are keyed by their numeric status code as a string,
+	// GRPCProtocol results by their gRPC status name, WebSocketProtocol
+	// results by "ok" or a close code, so a caller that doesn't care
+	// which protocol a run used can read one distribution either way.
+	OutcomeDist map[string]int
+
+	ResponseTimes []ResponseTime
+	LatencyDist   map[string]float64
+	ErrorDist     map[string]int
+
+	// Histogram is the raw latency histogram backing LatencyDist and
+	// ResponseTimes (its non-empty buckets, in ascending order), exposed
+	// for callers that want to re-derive arbitrary percentiles or merge
+	// histograms across processes.
+	Histogram []HistogramBucket
+
+	// ExactLatencies holds every successful request's latency, in seconds, sorted
+	// ascending, when Boomer.RecordAllLatencies is set. It's nil
+	// otherwise. Use it for exact percentiles on small runs where the
+	// histogram's log-linear bucketing isn't precise enough.
+	ExactLatencies []float64
 }
 
+// report is the built-in histogram-based Reporter: the one Run always
+// runs, producing the *ReportResult it returns. User-supplied Reporters
+// (see reporter.go) sit alongside it and get the same OnResult/OnTick
+// calls from Run's dispatcher goroutine.
 type report struct {
-	avgTotal float64
-	fastest  float64
-	slowest  float64
-	average  float64
-	rps      float64
+	avgSum  float64
+	average float64
+	rps     float64
 
-	results chan *result
-	total   time.Duration
+	total              time.Duration
+	recordAllLatencies bool
 
 	errorDist      map[string]int
 	statusCodeDist map[int]int
+	outcomeDist    map[string]int
+	hist           *hdrhistogram.Histogram
 	lats           []float64
 	sizeTotal      int64
+	readTotal      int64
+	writeTotal     int64
 }
 
-func newReport(size int, results chan *result, total time.Duration) *report {
+func newReport(size int, total time.Duration, recordAllLatencies bool) *report {
 	return &report{
-		results:        results,
-		total:          total,
-		statusCodeDist: make(map[int]int),
-		errorDist:      make(map[string]int),
+		total:              total,
+		recordAllLatencies: recordAllLatencies,
+		statusCodeDist:     make(map[int]int),
+		outcomeDist:        make(map[string]int),
+		errorDist:          make(map[string]int),
+		hist:               hdrhistogram.New(histogramLowestMicros, histogramHighestMicros, histogramSignificantFigures),
 	}
 }
 
-func (r *report) finalize() *ReportResult {
-	for {
-		select {
-		case res := <-r.results:
-			if res.err != nil {
-				r.errorDist[res.err.Error()]++
-			} else {
-				r.lats = append(r.lats, res.duration.Seconds())
-				r.avgTotal += res.duration.Seconds()
-				r.statusCodeDist[res.statusCode]++
-				if res.contentLength > 0 {
-					r.sizeTotal += res.contentLength
-				}
-			}
-		default:
-			r.rps = float64(len(r.lats)) / r.total.Seconds()
-			r.average = r.avgTotal / float64(len(r.lats))
-			return r.print()
+// OnResult folds one Result into the running aggregates. Latencies are
+// recorded straight into a streaming histogram instead of an
+// ever-growing slice, so an N=10M run costs ~17,000 int64 counters rather
+// than ~80MB plus an O(N log N) sort.
+func (r *report) OnResult(res Result) {
+	if res.Err != nil {
+		r.errorDist[res.Err.Error()]++
+		if res.Outcome != "" {
+			r.outcomeDist[res.Outcome]++
 		}
+		return
+	}
+	r.hist.RecordValue(res.Duration.Microseconds())
+	if r.recordAllLatencies {
+		r.lats = append(r.lats, res.Duration.Seconds())
 	}
+	r.avgSum += res.Duration.Seconds()
+	r.statusCodeDist[res.StatusCode]++
+	outcome := res.Outcome
+	if outcome == "" {
+		outcome = strconv.Itoa(res.StatusCode)
+	}
+	r.outcomeDist[outcome]++
+	if res.ContentLength > 0 {
+		r.sizeTotal += res.ContentLength
+	}
+	r.readTotal += res.ReadBytes
+	r.writeTotal += res.WriteBytes
 }
 
-func (r *report) print() *ReportResult {
-	result := new(ReportResult)
+// OnTick is a no-op: the final summary doesn't care about rolling
+// snapshots, only the totals it accumulates via OnResult.
+func (r *report) OnTick(Snapshot) {}
+
+// OnFinish is a no-op: report is the producer of the final
+// ReportResult (via Report), not a consumer of it.
+func (r *report) OnFinish(*ReportResult) {}
 
-	sort.Float64s(r.lats)
+// Snapshot summarizes the aggregates gathered so far, for Reporters
+// that want a rolling view of the run instead of (or in addition to)
+// per-result callbacks.
+func (r *report) Snapshot(elapsed time.Duration) Snapshot {
+	count := r.hist.TotalCount()
 
-	if len(r.lats) > 0 {
-		r.fastest = r.lats[0]
-		r.slowest = r.lats[len(r.lats)-1]
+	var errCount int64
+	for _, c := range r.errorDist {
+		errCount += int64(c)
+	}
 
+	var rps, errRate float64
+	if elapsed.Seconds() > 0 {
+		rps = float64(count) / elapsed.Seconds()
+	}
+	if total := count + errCount; total > 0 {
+		errRate = float64(errCount) / float64(total)
+	}
+
+	return Snapshot{
+		Elapsed:        elapsed,
+		Count:          count,
+		RequestsPerSec: rps,
+		P50Second:      microsToSeconds(r.hist.ValueAtQuantile(50)),
+		P95Second:      microsToSeconds(r.hist.ValueAtQuantile(95)),
+		ErrorRate:      errRate,
+	}
+}
+
+// Report computes the final ReportResult once every Result has been
+// folded in via OnResult.
+func (r *report) Report() *ReportResult {
+	count := r.hist.TotalCount()
+	r.rps = float64(count) / r.total.Seconds()
+	if count > 0 {
+		r.average = r.avgSum / float64(count)
+	}
+	return r.print()
+}
+
+func (r *report) print() *ReportResult {
+	result := new(ReportResult)
+
+	if r.hist.TotalCount() > 0 {
 		// Summary
 		result.Summary.TotalSecond = r.total.Seconds()
-		result.Summary.SlowestSecond = r.slowest
-		result.Summary.FastestSecond = r.fastest
+		result.Summary.SlowestSecond = microsToSeconds(r.hist.Max())
+		result.Summary.FastestSecond = microsToSeconds(r.hist.Min())
 		result.Summary.AverageSecond = r.average
 		result.Summary.RequestsPerSec = r.rps
 
 		if r.sizeTotal > 0 {
 			result.Summary.TotalSize = r.sizeTotal
-			result.Summary.SizePerRequest = r.sizeTotal / int64(len(r.lats))
+			result.Summary.SizePerRequest = r.sizeTotal / r.hist.TotalCount()
+		}
+
+		if r.total.Seconds() > 0 {
+			result.Summary.BytesReadPerSec = float64(r.readTotal) / r.total.Seconds()
+			result.Summary.BytesWrittenPerSec = float64(r.writeTotal) / r.total.Seconds()
 		}
 
 		r.printStatusCodes(result)
 		r.printHistogram(result)
 		r.printLatencies(result)
 
+		if r.recordAllLatencies {
+			sort.Float64s(r.lats)
+			result.ExactLatencies = r.lats
+		}
 	}
 
 	if len(r.errorDist) > 0 {
@@ -110,68 +229,88 @@ func (r *report) print() *ReportResult {
 	return result
 }
 
-// Prints percentile latencies.
+// Prints percentile latencies, read directly off the cumulative
+// histogram counts.
 func (r *report) printLatencies(result *ReportResult) {
-	pctls := []int{10, 25, 50, 75, 90, 95, 99}
-	data := make([]float64, len(pctls))
-	j := 0
-	for i := 0; i < len(r.lats) && j < len(pctls); i++ {
-		current := i * 100 / len(r.lats)
-		if current >= pctls[j] {
-			data[j] = r.lats[i]
-			j++
-		}
-	}
+	pctls := []float64{10, 25, 50, 75, 90, 95, 99}
 	result.LatencyDist = make(map[string]float64)
-	for i := 0; i < len(pctls); i++ {
-		if data[i] > 0 {
-			result.LatencyDist[fmt.Sprintf("%v%%", pctls[i])] = data[i]
+	for _, p := range pctls {
+		if v := r.hist.ValueAtQuantile(p); v > 0 {
+			result.LatencyDist[fmt.Sprintf("%v%%", p)] = microsToSeconds(v)
 		}
 	}
 }
 
+// printHistogram buckets the histogram's recorded values into 10
+// equal-width bars for display, plus a leading bar at the fastest
+// latency, reading bucket counts off the histogram's own distribution
+// rather than making a second pass over the raw latencies. Bar i counts
+// the values in (buckets[i-1], buckets[i]].
 func (r *report) printHistogram(result *ReportResult) {
 	bc := 10
+	fastest := microsToSeconds(r.hist.Min())
+	slowest := microsToSeconds(r.hist.Max())
+	bs := (slowest - fastest) / float64(bc)
+
 	buckets := make([]float64, bc+1)
-	counts := make([]int, bc+1)
-	bs := (r.slowest - r.fastest) / float64(bc)
-	for i := 0; i < bc; i++ {
-		buckets[i] = r.fastest + bs*float64(i)
-	}
-	buckets[bc] = r.slowest
-	var bi int
-	var max int
-	for i := 0; i < len(r.lats); {
-		if r.lats[i] <= buckets[bi] {
-			i++
-			counts[bi]++
-			if max < counts[bi] {
-				max = counts[bi]
+	for i := 0; i <= bc; i++ {
+		buckets[i] = fastest + bs*float64(i)
+	}
+
+	counts := make([]int64, bc+1)
+	result.Histogram = make([]HistogramBucket, 0)
+	for _, bar := range r.hist.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		result.Histogram = append(result.Histogram, HistogramBucket{
+			FromSecond: microsToSeconds(bar.From),
+			ToSecond:   microsToSeconds(bar.To),
+			Count:      bar.Count,
+		})
+		v := microsToSeconds(bar.To)
+		bi := bc
+		if bs > 0 {
+			bi = int(math.Ceil((v - fastest) / bs))
+			if bi < 0 {
+				bi = 0
+			} else if bi > bc {
+				bi = bc
 			}
-		} else if bi < len(buckets)-1 {
-			bi++
 		}
+		counts[bi] += bar.Count
 	}
 
-	result.ResponseTimes = make([]ResponseTime, 0, 6)
-	for i := 0; i < len(buckets); i++ {
-		// Normalize bar lengths.
-		var barLen int
+	var max int64
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+
+	result.ResponseTimes = make([]ResponseTime, 0, bc+1)
+	for i := 0; i <= bc; i++ {
+		var barLen int64
 		if max > 0 {
 			barLen = counts[i] * 40 / max
 		}
 
 		result.ResponseTimes = append(result.ResponseTimes, ResponseTime{
 			Second: buckets[i],
-			Count:  counts[i],
-			BarLen: barLen,
+			Count:  int(counts[i]),
+			BarLen: int(barLen),
 		})
 	}
 }
 
-// Prints status code distribution.
+func microsToSeconds(v int64) float64 {
+	return float64(v) / 1e6
+}
+
+// Prints status code and outcome distributions.
 func (r *report) printStatusCodes(result *ReportResult) {
 	result.StatusCodeDist = r.statusCodeDist
+	result.OutcomeDist = r.outcomeDist
 }
 
 func (r *report) printErrors(result *ReportResult) {
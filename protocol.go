@@ -0,0 +1,265 @@
+package boomer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// WorkerIDHeader is set by Boomer on every request handed to a Protocol,
+// so implementations that need per-virtual-user affinity (WebSocketProtocol's
+// one-connection-per-VU, or any future sticky-session protocol) have
+// something to key off without Protocol.Do itself taking a workerID.
+const WorkerIDHeader = "X-Boomer-Worker-Id"
+
+// Protocol lets Boomer fire requests over a transport other than the
+// built-in net/http and fasthttp engines. A Protocol still receives an
+// *http.Request built by nextRequest (a static Request/RequestBody or a
+// RequestProvider) — non-HTTP implementations just reinterpret its URL,
+// Header and Body as their own addressing, metadata and payload, so
+// every existing RequestProvider keeps working unchanged. Do reports a
+// Result for the call; the non-nil error return is reserved for
+// failures that mean the protocol can't be used at all (e.g. a bad
+// Target), as opposed to a single call failing, which belongs in
+// Result.Err so it's counted like any other request outcome.
+type Protocol interface {
+	Do(ctx context.Context, req *http.Request) (Result, error)
+}
+
+// runProtocolWorker is runWorker/runFastWorker's sibling for a
+// Boomer.Protocol. It's chosen over them by runWorkers whenever Protocol
+// is set, regardless of Engine.
+func (b *Boomer) runProtocolWorker(ctx context.Context, workerID int, wg *sync.WaitGroup, permits chan struct{}) {
+	var seq int
+	for range permits {
+		seq++
+
+		req, err := b.nextRequest(ctx, workerID, seq)
+		if err != nil {
+			b.results <- Result{Err: err}
+			wg.Done()
+			continue
+		}
+
+		// Started only once nextRequest has returned, so a
+		// ChainProvider's unmeasured setup steps (e.g. a login call)
+		// never leak into Result.Duration.
+		s := time.Now()
+		req.Header.Set(WorkerIDHeader, fmt.Sprint(workerID))
+
+		res, err := b.Protocol.Do(ctx, req)
+		if err != nil {
+			res = Result{Err: err}
+		}
+		if res.Duration == 0 {
+			res.Duration = time.Now().Sub(s)
+		}
+		b.results <- res
+		wg.Done()
+	}
+}
+
+// GRPCProtocol invokes a single unary gRPC method per request, over one
+// shared ClientConn (gRPC already multiplexes calls over HTTP/2, unlike
+// the per-VU connections WebSocketProtocol needs). It doesn't resolve
+// methods by name via server reflection; callers supply NewRequestMessage
+// and NewReplyMessage, which is what you get for free from .proto-generated
+// Go code, reflection-based dynamic dispatch is a larger follow-up if
+// method names need to be data-driven at runtime.
+type GRPCProtocol struct {
+	// Target is the dial target, e.g. "localhost:50051".
+	Target string
+
+	// Method is the fully-qualified method path, e.g.
+	// "/greeter.Greeter/SayHello".
+	Method string
+
+	// DialOptions is passed to grpc.NewClient; callers must at least
+	// supply transport credentials (grpc.WithTransportCredentials).
+	DialOptions []grpc.DialOption
+
+	// NewRequestMessage builds the request message for one call from the
+	// *http.Request Boomer handed in (its Body, Header etc, same as any
+	// other RequestProvider output).
+	NewRequestMessage func(req *http.Request) (proto.Message, error)
+
+	// NewReplyMessage returns a fresh, empty reply message to decode
+	// the response into.
+	NewReplyMessage func() proto.Message
+
+	once    sync.Once
+	conn    *grpc.ClientConn
+	dialErr error
+}
+
+func (g *GRPCProtocol) Do(ctx context.Context, req *http.Request) (Result, error) {
+	g.once.Do(func() {
+		g.conn, g.dialErr = grpc.NewClient(g.Target, g.DialOptions...)
+	})
+	if g.dialErr != nil {
+		return Result{}, g.dialErr
+	}
+
+	reqMsg, err := g.NewRequestMessage(req)
+	if err != nil {
+		return Result{Err: err}, nil
+	}
+	replyMsg := g.NewReplyMessage()
+
+	start := time.Now()
+	err = g.conn.Invoke(ctx, g.Method, reqMsg, replyMsg)
+	dur := time.Now().Sub(start)
+	if err != nil {
+		return Result{Err: err, Duration: dur, Outcome: grpcStatusOutcome(err)}, nil
+	}
+	return Result{Duration: dur, Outcome: "OK"}, nil
+}
+
+// Close releases the underlying ClientConn.
+func (g *GRPCProtocol) Close() error {
+	if g.conn == nil {
+		return nil
+	}
+	return g.conn.Close()
+}
+
+// WebSocketProtocol opens one connection per virtual user (keyed by the
+// WorkerIDHeader Boomer sets on every request) and measures the
+// round-trip time of a single request-message/reply-message exchange
+// over it per call, rather than dialing fresh for every message the way
+// an HTTP request/response pair would.
+type WebSocketProtocol struct {
+	// URL is the ws:// or wss:// endpoint to dial.
+	URL string
+
+	// Header is sent with the opening handshake.
+	Header http.Header
+
+	// MessageType is passed to Conn.WriteMessage, e.g.
+	// websocket.TextMessage (the default) or websocket.BinaryMessage.
+	MessageType int
+
+	// ReadTimeout bounds how long Do waits for a reply. Zero means no
+	// timeout.
+	ReadTimeout time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*websocket.Conn
+}
+
+func (w *WebSocketProtocol) Do(ctx context.Context, req *http.Request) (Result, error) {
+	workerID := req.Header.Get(WorkerIDHeader)
+
+	conn, err := w.connFor(ctx, workerID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// conn.WriteMessage/ReadMessage have no ctx parameter, so without
+	// this, a call stuck on a non-responding peer would never return
+	// and wg.Wait would hang past ctx cancellation. Dropping the
+	// connection unblocks both with an error; the watcher exits via
+	// done once Do returns normally.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.dropConn(workerID)
+		case <-done:
+		}
+	}()
+
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return Result{Err: err}, nil
+		}
+		req.Body.Close()
+	}
+
+	msgType := w.MessageType
+	if msgType == 0 {
+		msgType = websocket.TextMessage
+	}
+
+	start := time.Now()
+	if err := conn.WriteMessage(msgType, body); err != nil {
+		w.dropConn(workerID)
+		return Result{Err: err, Outcome: "write-error"}, nil
+	}
+
+	if w.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(w.ReadTimeout))
+	}
+	_, reply, err := conn.ReadMessage()
+	dur := time.Now().Sub(start)
+	if err != nil {
+		w.dropConn(workerID)
+		return Result{Err: err, Duration: dur, Outcome: websocketCloseOutcome(err)}, nil
+	}
+
+	return Result{Duration: dur, ContentLength: int64(len(reply)), Outcome: "ok"}, nil
+}
+
+func (w *WebSocketProtocol) connFor(ctx context.Context, workerID string) (*websocket.Conn, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if conn, ok := w.conns[workerID]; ok {
+		return conn, nil
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, w.URL, w.Header)
+	if err != nil {
+		return nil, err
+	}
+	if w.conns == nil {
+		w.conns = make(map[string]*websocket.Conn)
+	}
+	w.conns[workerID] = conn
+	return conn, nil
+}
+
+func (w *WebSocketProtocol) dropConn(workerID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if conn, ok := w.conns[workerID]; ok {
+		conn.Close()
+		delete(w.conns, workerID)
+	}
+}
+
+// Close closes every open per-virtual-user connection.
+func (w *WebSocketProtocol) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, conn := range w.conns {
+		conn.Close()
+		delete(w.conns, id)
+	}
+	return nil
+}
+
+// grpcStatusOutcome turns a gRPC error into its status code's name
+// (e.g. "NotFound"), so GRPCProtocol's outcomes read the same way
+// HTTP's numeric status codes do, just in gRPC's own vocabulary.
+func grpcStatusOutcome(err error) string {
+	return status.Code(err).String()
+}
+
+func websocketCloseOutcome(err error) string {
+	if ce, ok := err.(*websocket.CloseError); ok {
+		return fmt.Sprintf("close-%d", ce.Code)
+	}
+	return "read-error"
+}
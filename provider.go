@@ -0,0 +1,514 @@
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// RequestProvider builds the request for one iteration of a worker's
+// loop. workerID identifies which of Boomer.C workers is calling (so a
+// provider can keep per-virtual-user state, as ChainProvider does), and
+// seq is that worker's own 1-based iteration count.
+type RequestProvider interface {
+	Next(ctx context.Context, workerID, seq int) (*http.Request, error)
+}
+
+// templateData is what a TemplateProvider template's {{.WorkerID}} and
+// {{.Seq}} refer to.
+type templateData struct {
+	WorkerID int
+	Seq      int
+}
+
+// templateFuncNames are parsed into every TemplateProvider template so
+// Parse succeeds; the real closures (built per call in
+// TemplateProvider.Next) replace them before each Execute.
+var templateFuncNames = template.FuncMap{
+	"randInt":  func(int, int) int { return 0 },
+	"uuid":     func() string { return "" },
+	"now":      func() string { return "" },
+	"csvField": func(string, string) (string, error) { return "", nil },
+}
+
+// TemplateProvider builds the URL, headers and body from Go text/template
+// strings, so every iteration can look different instead of hammering
+// the same URL forever. Templates see {{.WorkerID}} and {{.Seq}}, plus
+// the functions randInt, uuid, now and csvField (reads a column out of a
+// lazily-loaded, cached CSV file, advancing one row per Next call).
+type TemplateProvider struct {
+	Method          string
+	URLTemplate     string
+	HeaderTemplates map[string]string
+	BodyTemplate    string
+
+	once        sync.Once
+	parseErr    error
+	urlTmpl     *template.Template
+	bodyTmpl    *template.Template
+	headerTmpls map[string]*template.Template
+
+	// mu serializes Next: the underlying *template.Template has its
+	// FuncMap overwritten with this call's closures right before
+	// Execute, which isn't safe to do concurrently.
+	mu     sync.Mutex
+	row    int64
+	tables map[string]*csvTable
+}
+
+func (p *TemplateProvider) Next(ctx context.Context, workerID, seq int) (*http.Request, error) {
+	p.once.Do(p.parse)
+	if p.parseErr != nil {
+		return nil, p.parseErr
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.row++
+	row := p.row
+	data := templateData{WorkerID: workerID, Seq: seq}
+	funcs := template.FuncMap{
+		"randInt": func(min, max int) int { return min + mathrand.Intn(max-min+1) },
+		"uuid":    newUUIDv4,
+		"now":     func() string { return time.Now().Format(time.RFC3339Nano) },
+		"csvField": func(file, field string) (string, error) {
+			return p.csvField(file, field, row)
+		},
+	}
+
+	method := p.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	url, err := p.render(p.urlTmpl, funcs, data)
+	if err != nil {
+		return nil, fmt.Errorf("boomer: rendering URL template: %w", err)
+	}
+	body, err := p.render(p.bodyTmpl, funcs, data)
+	if err != nil {
+		return nil, fmt.Errorf("boomer: rendering body template: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, tmpl := range p.headerTmpls {
+		v, err := p.render(tmpl, funcs, data)
+		if err != nil {
+			return nil, fmt.Errorf("boomer: rendering %s header template: %w", name, err)
+		}
+		req.Header.Set(name, v)
+	}
+	return req, nil
+}
+
+func (p *TemplateProvider) parse() {
+	p.urlTmpl, p.parseErr = template.New("url").Funcs(templateFuncNames).Parse(p.URLTemplate)
+	if p.parseErr != nil {
+		return
+	}
+	body := p.BodyTemplate
+	p.bodyTmpl, p.parseErr = template.New("body").Funcs(templateFuncNames).Parse(body)
+	if p.parseErr != nil {
+		return
+	}
+	p.headerTmpls = make(map[string]*template.Template, len(p.HeaderTemplates))
+	for name, text := range p.HeaderTemplates {
+		tmpl, err := template.New(name).Funcs(templateFuncNames).Parse(text)
+		if err != nil {
+			p.parseErr = err
+			return
+		}
+		p.headerTmpls[name] = tmpl
+	}
+}
+
+func (p *TemplateProvider) render(tmpl *template.Template, funcs template.FuncMap, data templateData) (string, error) {
+	tmpl.Funcs(funcs)
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// csvTable is a CSV file loaded into memory once and indexed by header
+// name, shared by every csvField call across a TemplateProvider's life.
+type csvTable struct {
+	header map[string]int
+	rows   [][]string
+}
+
+func (p *TemplateProvider) csvField(file, field string, row int64) (string, error) {
+	if p.tables == nil {
+		p.tables = make(map[string]*csvTable)
+	}
+	t, ok := p.tables[file]
+	if !ok {
+		var err error
+		t, err = loadCSVTable(file)
+		if err != nil {
+			return "", err
+		}
+		p.tables[file] = t
+	}
+	col, ok := t.header[field]
+	if !ok {
+		return "", fmt.Errorf("boomer: %s has no column %q", file, field)
+	}
+	if len(t.rows) == 0 {
+		return "", fmt.Errorf("boomer: %s has no data rows", file)
+	}
+	r := t.rows[int(row)%len(t.rows)]
+	if col >= len(r) {
+		return "", nil
+	}
+	return r[col], nil
+}
+
+func loadCSVTable(path string) (*csvTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	all, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("boomer: %s is empty", path)
+	}
+
+	header := make(map[string]int, len(all[0]))
+	for i, name := range all[0] {
+		header[name] = i
+	}
+	return &csvTable{header: header, rows: all[1:]}, nil
+}
+
+// newUUIDv4 returns a random RFC 4122 version-4 UUID.
+func newUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// CSVProvider reads a CSV file (with a header row naming the columns)
+// into memory in full on the first Next call, then hands one row per
+// call to Build to make a request, so a run
+// can exercise one real row of test data per iteration instead of a
+// single fixed request. Rows are assigned round-robin across workers,
+// or shuffled once up front if Shuffle is set.
+type CSVProvider struct {
+	Path    string
+	Shuffle bool
+	// Build turns one CSV row (keyed by its header column name) into a
+	// request.
+	Build func(row map[string]string, workerID, seq int) (*http.Request, error)
+
+	once    sync.Once
+	loadErr error
+	header  []string
+	rows    [][]string
+	order   []int
+	counter int64
+
+	mu sync.Mutex
+}
+
+func (c *CSVProvider) Next(ctx context.Context, workerID, seq int) (*http.Request, error) {
+	c.once.Do(c.load)
+	if c.loadErr != nil {
+		return nil, c.loadErr
+	}
+
+	c.mu.Lock()
+	n := len(c.order)
+	if n == 0 {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("boomer: %s has no data rows", c.Path)
+	}
+	// counter is shared across every worker, unlike seq (each worker's
+	// own private iteration count), so concurrent workers advance
+	// through distinct rows instead of all landing on the same one.
+	i := atomic.AddInt64(&c.counter, 1) - 1
+	row := c.rows[c.order[int(i)%n]]
+	c.mu.Unlock()
+
+	fields := make(map[string]string, len(c.header))
+	for i, name := range c.header {
+		if i < len(row) {
+			fields[name] = row[i]
+		}
+	}
+
+	req, err := c.Build(fields, workerID, seq)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+func (c *CSVProvider) load() {
+	f, err := os.Open(c.Path)
+	if err != nil {
+		c.loadErr = err
+		return
+	}
+	defer f.Close()
+
+	all, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		c.loadErr = err
+		return
+	}
+	if len(all) == 0 {
+		c.loadErr = fmt.Errorf("boomer: %s is empty", c.Path)
+		return
+	}
+
+	c.header = all[0]
+	c.rows = all[1:]
+	c.order = make([]int, len(c.rows))
+	for i := range c.order {
+		c.order[i] = i
+	}
+	if c.Shuffle {
+		mathrand.Shuffle(len(c.order), func(i, j int) { c.order[i], c.order[j] = c.order[j], c.order[i] })
+	}
+}
+
+// JSONLProvider is CSVProvider's sibling for newline-delimited JSON: one
+// JSON object per line, all loaded into memory on the first Next call
+// and handed to Build one at a time instead of a CSV row.
+type JSONLProvider struct {
+	Path    string
+	Shuffle bool
+	Build   func(row map[string]interface{}, workerID, seq int) (*http.Request, error)
+
+	once    sync.Once
+	loadErr error
+	rows    []map[string]interface{}
+	order   []int
+	counter int64
+
+	mu sync.Mutex
+}
+
+func (j *JSONLProvider) Next(ctx context.Context, workerID, seq int) (*http.Request, error) {
+	j.once.Do(j.load)
+	if j.loadErr != nil {
+		return nil, j.loadErr
+	}
+
+	j.mu.Lock()
+	n := len(j.order)
+	if n == 0 {
+		j.mu.Unlock()
+		return nil, fmt.Errorf("boomer: %s has no rows", j.Path)
+	}
+	// counter is shared across every worker, unlike seq (each worker's
+	// own private iteration count), so concurrent workers advance
+	// through distinct rows instead of all landing on the same one.
+	i := atomic.AddInt64(&j.counter, 1) - 1
+	row := j.rows[j.order[int(i)%n]]
+	j.mu.Unlock()
+
+	req, err := j.Build(row, workerID, seq)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+func (j *JSONLProvider) load() {
+	f, err := os.Open(j.Path)
+	if err != nil {
+		j.loadErr = err
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(line, &row); err != nil {
+			j.loadErr = err
+			return
+		}
+		j.rows = append(j.rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		j.loadErr = err
+		return
+	}
+	if len(j.rows) == 0 {
+		j.loadErr = fmt.Errorf("boomer: %s has no rows", j.Path)
+		return
+	}
+
+	j.order = make([]int, len(j.rows))
+	for i := range j.order {
+		j.order[i] = i
+	}
+	if j.Shuffle {
+		mathrand.Shuffle(len(j.order), func(i, k int) { j.order[i], j.order[k] = j.order[k], j.order[i] })
+	}
+}
+
+// ChainStep is one request in a ChainProvider sequence.
+type ChainStep struct {
+	// Request builds this step's request from the variables extracted
+	// by earlier steps.
+	Request func(vars map[string]string) (*http.Request, error)
+
+	// ExtractJSON pulls top-level fields out of a JSON response body
+	// into vars, keyed by the variable name each field is stored under.
+	ExtractJSON map[string]string
+
+	// ExtractRegex pulls the first capture group of each regexp out of
+	// the response body into vars.
+	ExtractRegex map[string]*regexp.Regexp
+
+	// Extract is a final escape hatch for anything ExtractJSON and
+	// ExtractRegex can't express, e.g. walking a nested JSON path.
+	Extract func(resp *http.Response, vars map[string]string) error
+}
+
+// ChainProvider runs Steps in sequence per iteration, carrying named
+// variables extracted from one response into the next step's request,
+// e.g. login -> extract an auth token -> hit an authenticated endpoint.
+// All but the last step are executed internally (and not measured);
+// the last step's request is returned for the caller to time as usual.
+// Variables persist per workerID across iterations, so a step can read
+// values extracted in an earlier iteration; every step still runs on
+// every iteration.
+type ChainProvider struct {
+	Steps []ChainStep
+
+	// Client runs the unmeasured steps. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	mu   sync.Mutex
+	vars map[int]map[string]string
+}
+
+func (c *ChainProvider) Next(ctx context.Context, workerID, seq int) (*http.Request, error) {
+	if len(c.Steps) == 0 {
+		return nil, fmt.Errorf("boomer: ChainProvider has no steps")
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	vars := c.varsFor(workerID)
+
+	for i, step := range c.Steps {
+		req, err := step.Request(vars)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+
+		if i == len(c.Steps)-1 {
+			return req, nil
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("boomer: chain step %d: %w", i, err)
+		}
+		err = extractVars(resp, vars, step)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("boomer: chain step %d: %w", i, err)
+		}
+	}
+	panic("unreachable")
+}
+
+func extractVars(resp *http.Response, vars map[string]string, step ChainStep) error {
+	needsBody := len(step.ExtractJSON) > 0 || len(step.ExtractRegex) > 0 || step.Extract != nil
+	if !needsBody {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = newRewoundBody(body)
+
+	if len(step.ExtractJSON) > 0 {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return err
+		}
+		for name, field := range step.ExtractJSON {
+			if v, ok := fields[field]; ok {
+				vars[name] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	for name, re := range step.ExtractRegex {
+		if m := re.FindSubmatch(body); len(m) > 1 {
+			vars[name] = string(m[1])
+		}
+	}
+
+	if step.Extract != nil {
+		resp.Body = newRewoundBody(body)
+		if err := step.Extract(resp, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRewoundBody(body []byte) io.ReadCloser {
+	return ioutil.NopCloser(bytes.NewReader(body))
+}
+
+func (c *ChainProvider) varsFor(workerID int) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.vars == nil {
+		c.vars = make(map[int]map[string]string)
+	}
+	v, ok := c.vars[workerID]
+	if !ok {
+		v = make(map[string]string)
+		c.vars[workerID] = v
+	}
+	return v
+}
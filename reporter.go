@@ -0,0 +1,103 @@
+package boomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reporter is notified as a run progresses: once per completed request,
+// periodically with a rolling Snapshot, and once with the final
+// ReportResult. Implementations must not block for long, since they all
+// share Run's single dispatcher goroutine.
+type Reporter interface {
+	OnResult(Result)
+	OnTick(Snapshot)
+	OnFinish(*ReportResult)
+}
+
+// Snapshot is a rolling view of a run in progress.
+type Snapshot struct {
+	Elapsed        time.Duration
+	Count          int64
+	RequestsPerSec float64
+	P50Second      float64
+	P95Second      float64
+	ErrorRate      float64
+}
+
+// JSONLReporter writes one JSON line per completed request to Writer.
+// It ignores Snapshots and the final ReportResult.
+type JSONLReporter struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// jsonlResult is the JSON shape written per line; Result.Err doesn't
+// marshal usefully as-is, so it's flattened to a string.
+type jsonlResult struct {
+	Err           string  `json:"err,omitempty"`
+	StatusCode    int     `json:"statusCode"`
+	DurationSecs  float64 `json:"durationSecs"`
+	ContentLength int64   `json:"contentLength"`
+	ReadBytes     int64   `json:"readBytes"`
+	WriteBytes    int64   `json:"writeBytes"`
+}
+
+func (j *JSONLReporter) OnResult(res Result) {
+	line := jsonlResult{
+		StatusCode:    res.StatusCode,
+		DurationSecs:  res.Duration.Seconds(),
+		ContentLength: res.ContentLength,
+		ReadBytes:     res.ReadBytes,
+		WriteBytes:    res.WriteBytes,
+	}
+	if res.Err != nil {
+		line.Err = res.Err.Error()
+	}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Writer.Write(b)
+}
+
+func (j *JSONLReporter) OnTick(Snapshot)        {}
+func (j *JSONLReporter) OnFinish(*ReportResult) {}
+
+// TickReporter prints a rolling RPS/p50/p95/error-rate snapshot every
+// Interval. It ignores individual Results.
+type TickReporter struct {
+	Interval time.Duration
+	Writer   io.Writer
+
+	last time.Duration
+}
+
+func (t *TickReporter) OnResult(Result) {}
+
+func (t *TickReporter) OnTick(snap Snapshot) {
+	if t.Interval > 0 && snap.Elapsed-t.last < t.Interval {
+		return
+	}
+	t.last = snap.Elapsed
+
+	w := t.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintf(w, "[%6s] count=%d rps=%.1f p50=%.4fs p95=%.4fs errRate=%.2f%%\n",
+		snap.Elapsed.Round(100*time.Millisecond), snap.Count, snap.RequestsPerSec,
+		snap.P50Second, snap.P95Second, snap.ErrorRate*100)
+}
+
+func (t *TickReporter) OnFinish(*ReportResult) {}